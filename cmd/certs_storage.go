@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/go-acme/lego/v3/log"
+	"github.com/go-acme/lego/v3/renewer"
+	"github.com/urfave/cli"
+)
+
+// CertificatesStorage and ResourceMeta are aliases for the renewer
+// package's types: certificate storage now lives there so it can be reused
+// outside the CLI (see renewer.Renewer), and aliasing keeps every existing
+// reference in this package working unchanged.
+type CertificatesStorage = renewer.CertificatesStorage
+type ResourceMeta = renewer.ResourceMeta
+
+// storageFlag selects which CertificatesStorage backend to use. It's a
+// per-command flag (not global), so any cert-emitting command needs it
+// added to its own Flags to pick up a non-default backend; "renew" is the
+// only such command in this tree, and already has it.
+var storageFlag = cli.StringFlag{
+	Name:  "storage",
+	Usage: "Storage backend for certificates, e.g. file:// (default, rooted at --path) or sqlite:///path/to/lego.db.",
+}
+
+// NewCertificatesStorage creates the CertificatesStorage backend selected by
+// --storage. It defaults to file storage rooted at --path.
+func NewCertificatesStorage(ctx *cli.Context) CertificatesStorage {
+	storage := ctx.String("storage")
+
+	switch {
+	case storage == "", strings.HasPrefix(storage, "file://"):
+		return renewer.NewFileCertificatesStorage(ctx.GlobalString("path"), ctx.GlobalBool("pem"), ctx.GlobalString("filename"))
+
+	case strings.HasPrefix(storage, "sqlite://"):
+		db, err := renewer.NewSQLCertificatesStorage(strings.TrimPrefix(storage, "sqlite://"), ctx.GlobalString("email"))
+		if err != nil {
+			log.Fatalf("Unable to initialize the SQL certificates storage: %v", err)
+		}
+		return db
+
+	default:
+		log.Fatalf("Unsupported --storage value %q, expected a file:// or sqlite:// URI", storage)
+		return nil
+	}
+}
@@ -3,12 +3,13 @@ package cmd
 import (
 	"crypto"
 	"crypto/x509"
+	"fmt"
 	"time"
 
 	"github.com/go-acme/lego/v3/certcrypto"
 	"github.com/go-acme/lego/v3/certificate"
-	"github.com/go-acme/lego/v3/lego"
 	"github.com/go-acme/lego/v3/log"
+	"github.com/go-acme/lego/v3/renewer"
 	"github.com/urfave/cli"
 )
 
@@ -58,49 +59,117 @@ func createRenew() cli.Command {
 				Name:  "renew-hook",
 				Usage: "Define a hook. The hook is executed only when the certificates are effectively renewed.",
 			},
+			cli.BoolFlag{
+				Name:  "daemon",
+				Usage: "Run in the foreground as a supervising process that periodically renews every certificate under the configured storage, instead of exiting after a single pass.",
+			},
+			cli.DurationFlag{
+				Name:  "check-interval",
+				Value: 12 * time.Hour,
+				Usage: "Used with --daemon. Interval between two passes over the certificates.",
+			},
+			cli.StringFlag{
+				Name:  "metrics-addr",
+				Usage: "Used with --daemon. If set, serve per-domain renewal metrics (Prometheus text format) on this address, e.g. :9090.",
+			},
+			storageFlag,
 		},
 	}
 }
 
+// renew is a thin CLI wrapper around the renewer package: it does the
+// CLI-specific setup (account, challenges, flag parsing) and then renews
+// through the renewer.Renewer's client and storage, with renewer.RenewOnce
+// for backoff and renewer.NeedRenewal for the threshold check.
 func renew(ctx *cli.Context) error {
-	account, client := setup(ctx, NewAccountsStorage(ctx))
-	setupChallenges(ctx, client)
-
-	if account.Registration == nil {
-		log.Fatalf("Account %s is not registered. Use 'run' to register a new account.\n", account.Email)
+	rnw, account, err := NewRenewerFromCLI(ctx)
+	if err != nil {
+		return err
 	}
 
-	certsStorage := NewCertificatesStorage(ctx)
-
 	bundle := !ctx.Bool("no-bundle")
 
+	if ctx.Bool("daemon") {
+		return renewDaemon(ctx, rnw, bundle)
+	}
+
 	meta := map[string]string{renewEnvAccountEmail: account.Email}
 
 	// CSR
 	if ctx.GlobalIsSet("csr") {
-		return renewForCSR(ctx, client, certsStorage, bundle, meta)
+		return renewForCSR(ctx, rnw, bundle, meta)
 	}
 
 	// Domains
-	return renewForDomains(ctx, client, certsStorage, bundle, meta)
+	return renewForDomains(ctx, rnw, bundle, meta)
 }
 
-func renewForDomains(ctx *cli.Context, client *lego.Client, certsStorage *CertificatesStorage, bundle bool, meta map[string]string) error {
+// NewRenewerFromCLI builds a renewer.Renewer from CLI flags, reusing the
+// same account/client/challenge setup as every other command, and also
+// returns the loaded account (the CLI needs its email for the renew-hook
+// environment, which is outside the renewer package's programmatic API).
+func NewRenewerFromCLI(ctx *cli.Context) (*renewer.Renewer, *Account, error) {
+	account, client := setup(ctx, NewAccountsStorage(ctx))
+	setupChallenges(ctx, client)
+
+	if account.Registration == nil {
+		log.Fatalf("Account %s is not registered. Use 'run' to register a new account.\n", account.Email)
+	}
+
+	rnw := &renewer.Renewer{
+		Client:    client,
+		Storage:   NewCertificatesStorage(ctx),
+		Threshold: time.Duration(ctx.Int("days")) * 24 * time.Hour,
+	}
+
+	return rnw, account, nil
+}
+
+func renewForDomains(ctx *cli.Context, rnw *renewer.Renewer, bundle bool, meta map[string]string) error {
 	domains := ctx.GlobalStringSlice("domains")
 	domain := domains[0]
 
+	certRes, err := renewOnce(domain, rnw.Storage, func() (*certificate.Resource, error) {
+		return renewDomain(ctx, rnw, domain, domains, bundle, ctx.Bool("reuse-key"), daysThreshold(ctx.Int("days")))
+	})
+	if err != nil {
+		return err
+	}
+	if certRes == nil {
+		return nil
+	}
+
+	meta[renewEnvCertDomain] = domain
+	meta[renewEnvCertPath] = rnw.Storage.GetFileName(domain, ".crt")
+	meta[renewEnvCertKeyPath] = rnw.Storage.GetFileName(domain, ".key")
+
+	return launchHook(ctx.String("renew-hook"), meta)
+}
+
+// renewDomain loads the existing certificate for domain, checks whether it needs renewal,
+// and if so obtains a replacement. It returns a nil Resource (and nil error) when the
+// certificate is not yet due for renewal.
+//
+// Unlike renewer.Renewer.Renew, it supports the CLI-only --reuse-key option and merging
+// in any extra --domains SANs, so it stays a cmd-local helper rather than moving to the
+// renewer package.
+func renewDomain(ctx *cli.Context, rnw *renewer.Renewer, domain string, domains []string, bundle, reuseKey bool, threshold time.Duration) (*certificate.Resource, error) {
 	// load the cert resource from files.
 	// We store the certificate, private key and metadata in different files
 	// as web servers would not be able to work with a combined file.
-	certificates, err := certsStorage.ReadCertificate(domain, ".crt")
+	certificates, err := rnw.Storage.ReadCertificate(domain, ".crt")
 	if err != nil {
-		log.Fatalf("Error while loading the certificate for domain %s\n\t%v", domain, err)
+		return nil, fmt.Errorf("error while loading the certificate for domain %s: %w", domain, err)
 	}
 
 	cert := certificates[0]
 
-	if !needRenewal(cert, domain, ctx.Int("days")) {
-		return nil
+	due, err := renewer.NeedRenewal(cert, domain, threshold)
+	if err != nil {
+		return nil, err
+	}
+	if !due {
+		return nil, nil
 	}
 
 	// This is just meant to be informal for the user.
@@ -110,15 +179,15 @@ func renewForDomains(ctx *cli.Context, client *lego.Client, certsStorage *Certif
 	certDomains := certcrypto.ExtractDomains(cert)
 
 	var privateKey crypto.PrivateKey
-	if ctx.Bool("reuse-key") {
-		keyBytes, errR := certsStorage.ReadFile(domain, ".key")
+	if reuseKey {
+		keyBytes, errR := rnw.Storage.ReadFile(domain, ".key")
 		if errR != nil {
-			log.Fatalf("Error while loading the private key for domain %s\n\t%v", domain, errR)
+			return nil, fmt.Errorf("error while loading the private key for domain %s: %w", domain, errR)
 		}
 
 		privateKey, errR = certcrypto.ParsePEMPrivateKey(keyBytes)
 		if errR != nil {
-			return errR
+			return nil, errR
 		}
 	}
 
@@ -128,75 +197,85 @@ func renewForDomains(ctx *cli.Context, client *lego.Client, certsStorage *Certif
 		PrivateKey: privateKey,
 		MustStaple: ctx.Bool("must-staple"),
 	}
-	certRes, err := client.Certificate.Obtain(request)
+	certRes, err := rnw.Client.Certificate.Obtain(request)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	certsStorage.SaveResource(certRes)
-
-	meta[renewEnvCertDomain] = domain
-	meta[renewEnvCertPath] = certsStorage.GetFileName(domain, ".crt")
-	meta[renewEnvCertKeyPath] = certsStorage.GetFileName(domain, ".key")
+	rnw.Storage.SaveResource(certRes)
 
-	return launchHook(ctx.String("renew-hook"), meta)
+	return certRes, nil
 }
 
-func renewForCSR(ctx *cli.Context, client *lego.Client, certsStorage *CertificatesStorage, bundle bool, meta map[string]string) error {
+func renewForCSR(ctx *cli.Context, rnw *renewer.Renewer, bundle bool, meta map[string]string) error {
 	csr, err := readCSRFile(ctx.GlobalString("csr"))
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	domain := csr.Subject.CommonName
 
+	certRes, err := renewOnce(domain, rnw.Storage, func() (*certificate.Resource, error) {
+		return renewCSR(ctx, rnw, csr, domain, bundle)
+	})
+	if err != nil {
+		return err
+	}
+	if certRes == nil {
+		return nil
+	}
+
+	meta[renewEnvCertDomain] = domain
+	meta[renewEnvCertPath] = rnw.Storage.GetFileName(domain, ".crt")
+	meta[renewEnvCertKeyPath] = rnw.Storage.GetFileName(domain, ".key")
+
+	return launchHook(ctx.String("renew-hook"), meta)
+}
+
+// renewCSR loads the existing certificate named by csr's common name,
+// checks whether it needs renewal, and if so obtains a replacement from csr.
+// It returns a nil Resource (and nil error) when the certificate is not yet
+// due for renewal.
+func renewCSR(ctx *cli.Context, rnw *renewer.Renewer, csr *x509.CertificateRequest, domain string, bundle bool) (*certificate.Resource, error) {
 	// load the cert resource from files.
 	// We store the certificate, private key and metadata in different files
 	// as web servers would not be able to work with a combined file.
-	certificates, err := certsStorage.ReadCertificate(domain, ".crt")
+	certificates, err := rnw.Storage.ReadCertificate(domain, ".crt")
 	if err != nil {
-		log.Fatalf("Error while loading the certificate for domain %s\n\t%v", domain, err)
+		return nil, fmt.Errorf("error while loading the certificate for domain %s: %w", domain, err)
 	}
 
 	cert := certificates[0]
 
-	if !needRenewal(cert, domain, ctx.Int("days")) {
-		return nil
+	due, err := renewer.NeedRenewal(cert, domain, daysThreshold(ctx.Int("days")))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !due {
+		return nil, nil
 	}
 
 	// This is just meant to be informal for the user.
 	timeLeft := cert.NotAfter.Sub(time.Now().UTC())
 	log.Infof("[%s] acme: Trying renewal with %d hours remaining", domain, int(timeLeft.Hours()))
 
-	certRes, err := client.Certificate.ObtainForCSR(*csr, bundle)
+	certRes, err := rnw.Client.Certificate.ObtainForCSR(*csr, bundle)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	certsStorage.SaveResource(certRes)
+	rnw.Storage.SaveResource(certRes)
 
-	meta[renewEnvCertDomain] = domain
-	meta[renewEnvCertPath] = certsStorage.GetFileName(domain, ".crt")
-	meta[renewEnvCertKeyPath] = certsStorage.GetFileName(domain, ".key")
-
-	return launchHook(ctx.String("renew-hook"), meta)
+	return certRes, nil
 }
 
-func needRenewal(x509Cert *x509.Certificate, domain string, days int) bool {
-	if x509Cert.IsCA {
-		log.Fatalf("[%s] Certificate bundle starts with a CA certificate", domain)
-	}
-
-	if days >= 0 {
-		notAfter := int(time.Until(x509Cert.NotAfter).Hours() / 24.0)
-		if notAfter > days {
-			log.Printf("[%s] The certificate expires in %d days, the number of days defined to perform the renewal is %d: no renewal.",
-				domain, notAfter, days)
-			return false
-		}
-	}
-
-	return true
+// daysThreshold converts the --days flag (an integer number of days, with a
+// negative value meaning "always renew") into the time.Duration that
+// renewer.NeedRenewal compares against. The daemon path jitters this
+// duration further (see jitterThreshold) to spread renewals of a fleet
+// enrolled on the same day across several check passes.
+func daysThreshold(days int) time.Duration {
+	return time.Duration(days) * 24 * time.Hour
 }
 
 func merge(prevDomains []string, nextDomains []string) []string {
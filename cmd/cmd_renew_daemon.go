@@ -0,0 +1,272 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/go-acme/lego/v3/certificate"
+	"github.com/go-acme/lego/v3/log"
+	"github.com/go-acme/lego/v3/renewer"
+	"github.com/urfave/cli"
+)
+
+// renewDaemonWorkers is the size of the bounded worker pool that drains the
+// (unbounded) queue of domains to check on every pass.
+const renewDaemonWorkers = 10
+
+// renewDaemon runs the supervising process behind `lego renew --daemon`.
+// It periodically walks every certificate known to rnw.Storage, renews the
+// ones inside the --days threshold, and keeps running until it receives
+// SIGINT/SIGTERM.
+func renewDaemon(ctx *cli.Context, rnw *renewer.Renewer, bundle bool) error {
+	interval := ctx.Duration("check-interval")
+	if interval <= 0 {
+		interval = 12 * time.Hour
+	}
+
+	metrics := newRenewalMetrics()
+	if addr := ctx.String("metrics-addr"); addr != "" {
+		go func() {
+			if err := serveRenewalMetrics(addr, metrics); err != nil {
+				log.Printf("metrics: server stopped: %v", err)
+			}
+		}()
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		sig := <-stop
+		log.Infof("daemon: received %s, shutting down", sig)
+		cancel()
+	}()
+
+	log.Infof("daemon: renewal checks every %s", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// run one pass immediately, then on every tick. runCtx lets a pass in
+	// flight be interrupted by the signal goroutine above instead of
+	// always running to completion before shutdown can take effect.
+	runRenewalPass(runCtx, ctx, rnw, bundle, metrics)
+
+	for {
+		if runCtx.Err() != nil {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+			runRenewalPass(runCtx, ctx, rnw, bundle, metrics)
+		case <-runCtx.Done():
+			return nil
+		}
+	}
+}
+
+// runRenewalPass walks every certificate under rnw.Storage and feeds domains
+// that may need renewal to a bounded worker pool fed by an unbounded channel,
+// so that a slow challenge provider for one domain never blocks the others.
+// runCtx being cancelled stops the pass from picking up further domains,
+// bounding shutdown latency even if the pass covers a large fleet.
+func runRenewalPass(runCtx context.Context, ctx *cli.Context, rnw *renewer.Renewer, bundle bool, metrics *renewalMetrics) {
+	domains, err := rnw.Storage.ListCertificates()
+	if err != nil {
+		log.Printf("daemon: could not list certificates: %v", err)
+		return
+	}
+
+	jobs := make(chan string, len(domains)) // holds the whole pass, bounded consumers below
+
+	var wg sync.WaitGroup
+	for i := 0; i < renewDaemonWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for domain := range jobs {
+				if runCtx.Err() != nil {
+					continue
+				}
+				renewDaemonDomain(ctx, rnw, bundle, domain, metrics)
+			}
+		}()
+	}
+
+loop:
+	for _, domain := range domains {
+		select {
+		case jobs <- domain:
+		case <-runCtx.Done():
+			break loop
+		}
+	}
+	close(jobs)
+
+	wg.Wait()
+}
+
+// renewDaemonDomain renews a single domain, recording the outcome in metrics
+// and firing the renew-hook on success. Failures are logged and counted, not
+// fatal: the daemon keeps going so one broken domain doesn't stop the fleet.
+// A domain still inside its backoff window (no Obtain call made this pass)
+// is recorded as a skip, not an attempt or a failure.
+func renewDaemonDomain(ctx *cli.Context, rnw *renewer.Renewer, bundle bool, domain string, metrics *renewalMetrics) {
+	threshold := jitterThreshold(daysThreshold(ctx.Int("days")))
+
+	certRes, err := renewOnce(domain, rnw.Storage, func() (*certificate.Resource, error) {
+		return renewDomain(ctx, rnw, domain, []string{domain}, bundle, ctx.Bool("reuse-key"), threshold)
+	})
+	if err != nil {
+		var backoff *renewer.BackoffError
+		if errors.As(err, &backoff) {
+			metrics.recordSkipUntil(domain, backoff.NextAttemptAt)
+			return
+		}
+
+		metrics.recordAttempt(domain)
+		log.Printf("[%s] daemon: renewal failed: %v", domain, err)
+		metrics.recordFailure(domain)
+		return
+	}
+	if certRes == nil {
+		metrics.recordSkip(domain, ctx.Duration("check-interval"))
+		return
+	}
+
+	metrics.recordAttempt(domain)
+	metrics.recordSuccess(domain)
+
+	meta := map[string]string{
+		renewEnvCertDomain:  domain,
+		renewEnvCertPath:    rnw.Storage.GetFileName(domain, ".crt"),
+		renewEnvCertKeyPath: rnw.Storage.GetFileName(domain, ".key"),
+	}
+	if err := launchHook(ctx.String("renew-hook"), meta); err != nil {
+		log.Printf("[%s] daemon: renew-hook failed: %v", domain, err)
+	}
+}
+
+// jitterThreshold spreads renewals across the check window by shaving 0-3
+// hours off threshold, so a large fleet enrolled on the same day doesn't
+// all cross into "needs renewal" in the same pass.
+func jitterThreshold(threshold time.Duration) time.Duration {
+	jitterHours := rand.Intn(4) //nolint:gosec // scheduling jitter, not security sensitive
+	return threshold - time.Duration(jitterHours)*time.Hour
+}
+
+// renewalMetrics tracks per-domain renewal attempts/successes/failures and
+// the next time a domain is expected to be attempted again, for the optional
+// Prometheus endpoint.
+type renewalMetrics struct {
+	mu      sync.Mutex
+	domains map[string]*domainMetrics
+}
+
+type domainMetrics struct {
+	attempts    uint64
+	successes   uint64
+	failures    uint64
+	nextAttempt time.Time
+}
+
+func newRenewalMetrics() *renewalMetrics {
+	return &renewalMetrics{domains: map[string]*domainMetrics{}}
+}
+
+func (m *renewalMetrics) entry(domain string) *domainMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	d, ok := m.domains[domain]
+	if !ok {
+		d = &domainMetrics{}
+		m.domains[domain] = d
+	}
+	return d
+}
+
+func (m *renewalMetrics) recordAttempt(domain string) {
+	atomic.AddUint64(&m.entry(domain).attempts, 1)
+}
+
+func (m *renewalMetrics) recordSuccess(domain string) {
+	atomic.AddUint64(&m.entry(domain).successes, 1)
+}
+
+func (m *renewalMetrics) recordFailure(domain string) {
+	atomic.AddUint64(&m.entry(domain).failures, 1)
+}
+
+// recordSkip records that domain was not yet due and is checked again on the
+// next pass, interval from now.
+func (m *renewalMetrics) recordSkip(domain string, interval time.Duration) {
+	m.recordSkipUntil(domain, time.Now().UTC().Add(interval))
+}
+
+// recordSkipUntil records that domain was not attempted this pass (not yet
+// due, or still inside a previously recorded backoff window) and won't be
+// attempted again before nextAttempt.
+func (m *renewalMetrics) recordSkipUntil(domain string, nextAttempt time.Time) {
+	d := m.entry(domain)
+	m.mu.Lock()
+	d.nextAttempt = nextAttempt
+	m.mu.Unlock()
+}
+
+// serveRenewalMetrics exposes metrics in the Prometheus text exposition
+// format on addr until the process exits.
+func serveRenewalMetrics(addr string, metrics *renewalMetrics) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, _ *http.Request) {
+		metrics.mu.Lock()
+		defer metrics.mu.Unlock()
+
+		var b strings.Builder
+		b.WriteString("# HELP lego_renew_attempts_total Number of renewal attempts per domain.\n")
+		b.WriteString("# TYPE lego_renew_attempts_total counter\n")
+		for domain, d := range metrics.domains {
+			fmt.Fprintf(&b, "lego_renew_attempts_total{domain=%q} %d\n", domain, atomic.LoadUint64(&d.attempts))
+		}
+
+		b.WriteString("# HELP lego_renew_successes_total Number of successful renewals per domain.\n")
+		b.WriteString("# TYPE lego_renew_successes_total counter\n")
+		for domain, d := range metrics.domains {
+			fmt.Fprintf(&b, "lego_renew_successes_total{domain=%q} %d\n", domain, atomic.LoadUint64(&d.successes))
+		}
+
+		b.WriteString("# HELP lego_renew_failures_total Number of failed renewals per domain.\n")
+		b.WriteString("# TYPE lego_renew_failures_total counter\n")
+		for domain, d := range metrics.domains {
+			fmt.Fprintf(&b, "lego_renew_failures_total{domain=%q} %d\n", domain, atomic.LoadUint64(&d.failures))
+		}
+
+		b.WriteString("# HELP lego_renew_next_attempt_timestamp_seconds Unix time of the next scheduled renewal attempt per domain.\n")
+		b.WriteString("# TYPE lego_renew_next_attempt_timestamp_seconds gauge\n")
+		for domain, d := range metrics.domains {
+			if d.nextAttempt.IsZero() {
+				continue
+			}
+			fmt.Fprintf(&b, "lego_renew_next_attempt_timestamp_seconds{domain=%q} %d\n", domain, d.nextAttempt.Unix())
+		}
+
+		_, _ = w.Write([]byte(b.String()))
+	})
+
+	server := &http.Server{Addr: addr, Handler: mux}
+	return server.ListenAndServe()
+}
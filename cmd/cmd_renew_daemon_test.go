@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJitterThreshold(t *testing.T) {
+	threshold := 30 * 24 * time.Hour
+
+	for i := 0; i < 50; i++ {
+		jittered := jitterThreshold(threshold)
+
+		assert.LessOrEqual(t, jittered, threshold)
+		assert.Greater(t, jittered, threshold-4*time.Hour)
+	}
+}
+
+func TestDaysThreshold(t *testing.T) {
+	assert.Equal(t, 30*24*time.Hour, daysThreshold(30))
+	assert.Equal(t, -24*time.Hour, daysThreshold(-1))
+}
+
+func TestRenewalMetrics_recordSkipUntil_doesNotCountAsAttemptOrFailure(t *testing.T) {
+	metrics := newRenewalMetrics()
+
+	nextAttempt := time.Now().UTC().Add(time.Hour)
+	metrics.recordSkipUntil("example.com", nextAttempt)
+
+	d := metrics.entry("example.com")
+	assert.Equal(t, uint64(0), d.attempts)
+	assert.Equal(t, uint64(0), d.failures)
+	assert.True(t, d.nextAttempt.Equal(nextAttempt))
+}
@@ -0,0 +1,8 @@
+package cmd
+
+import "github.com/go-acme/lego/v3/renewer"
+
+// renewOnce wraps an obtain attempt for domain with resilience (backoff on
+// failure, skip while still backing off). The logic lives in the renewer
+// package so it is shared with renewer.Renewer.
+var renewOnce = renewer.RenewOnce
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-acme/lego/challenge/dns01"
@@ -23,6 +24,7 @@ const (
 	EnvTTL                = envNamespace + "TTL"
 	EnvPropagationTimeout = envNamespace + "PROPAGATION_TIMEOUT"
 	EnvPollingInterval    = envNamespace + "POLLING_INTERVAL"
+	EnvSequenceInterval   = envNamespace + "SEQUENCE_INTERVAL"
 	EnvHTTPTimeout        = envNamespace + "HTTP_TIMEOUT"
 )
 
@@ -31,6 +33,7 @@ type Config struct {
 	APIKey             string
 	PropagationTimeout time.Duration
 	PollingInterval    time.Duration
+	SequenceInterval   time.Duration
 	TTL                int
 	HTTPClient         *http.Client
 }
@@ -41,6 +44,7 @@ func NewDefaultConfig() *Config {
 		TTL:                env.GetOrDefaultInt(EnvTTL, minTTL),
 		PropagationTimeout: env.GetOrDefaultSecond(EnvPropagationTimeout, 120*time.Second),
 		PollingInterval:    env.GetOrDefaultSecond(EnvPollingInterval, 2*time.Second),
+		SequenceInterval:   env.GetOrDefaultSecond(EnvSequenceInterval, 120*time.Second),
 		HTTPClient: &http.Client{
 			Timeout: env.GetOrDefaultSecond(EnvHTTPTimeout, 30*time.Second),
 		},
@@ -51,6 +55,9 @@ func NewDefaultConfig() *Config {
 type DNSProvider struct {
 	config *Config
 	client *internal.Client
+
+	zonesMu sync.Mutex
+	zones   map[string]string // fqdn -> zone, cached for the Present/CleanUp pair
 }
 
 // NewDNSProvider returns a DNSProvider instance configured for ArvanCloud.
@@ -87,7 +94,7 @@ func NewDNSProviderConfig(config *Config) (*DNSProvider, error) {
 		client.HTTPClient = config.HTTPClient
 	}
 
-	return &DNSProvider{config: config, client: client}, nil
+	return &DNSProvider{config: config, client: client, zones: make(map[string]string)}, nil
 }
 
 // Timeout returns the timeout and interval to use when checking for DNS
@@ -96,13 +103,25 @@ func (d *DNSProvider) Timeout() (timeout, interval time.Duration) {
 	return d.config.PropagationTimeout, d.config.PollingInterval
 }
 
+// Sequential All DNS challenges for this provider will be resolved sequentially.
+// Returns the interval between each iteration, so several challenges under
+// the same zone don't race each other against the ArvanCloud API.
+func (d *DNSProvider) Sequential() time.Duration {
+	return d.config.SequenceInterval
+}
+
 // Present creates a TXT record to fulfill the dns-01 challenge.
 func (d *DNSProvider) Present(domain, token, keyAuth string) error {
 	fqdn, value := dns01.GetRecord(domain, keyAuth)
 
+	zone, err := d.getZone(fqdn)
+	if err != nil {
+		return fmt.Errorf("arvanCloud: %w", err)
+	}
+
 	record := internal.DNSRecord{
 		Type:          "txt",
-		Name:          d.extractRecordName(fqdn, domain),
+		Name:          extractRecordName(fqdn, zone),
 		Value:         internal.TxtValue{Text: value},
 		TTL:           d.config.TTL,
 		UpstreamHTTPS: "default",
@@ -113,8 +132,8 @@ func (d *DNSProvider) Present(domain, token, keyAuth string) error {
 		},
 	}
 
-	if err := d.client.CreateRecord(domain, record); err != nil {
-		return fmt.Errorf("arvanCloud: failed to add TXT record: fqdn=%s, domain name=%s: %w", fqdn, domain, err)
+	if err := d.client.CreateRecord(zone, record); err != nil {
+		return fmt.Errorf("arvanCloud: failed to add TXT record: fqdn=%s, zone=%s: %w", fqdn, zone, err)
 	}
 
 	return nil
@@ -124,24 +143,56 @@ func (d *DNSProvider) Present(domain, token, keyAuth string) error {
 func (d *DNSProvider) CleanUp(domain, token, keyAuth string) error {
 	fqdn, value := dns01.GetRecord(domain, keyAuth)
 
-	recordName := d.extractRecordName(fqdn, domain)
+	zone, err := d.getZone(fqdn)
+	if err != nil {
+		return fmt.Errorf("arvanCloud: %w", err)
+	}
+	defer d.forgetZone(fqdn)
+
+	recordName := extractRecordName(fqdn, zone)
 
-	record, err := d.client.GetTxtRecord(domain, recordName, value)
+	record, err := d.client.GetTxtRecord(zone, recordName, value)
 	if err != nil {
 		return fmt.Errorf("arvanCloud: %w", err)
 	}
 
-	if err := d.client.DeleteRecord(domain, record.ID); err != nil {
+	if err := d.client.DeleteRecord(zone, record.ID); err != nil {
 		return fmt.Errorf("arvanCloud: failed to delate TXT record: id=%s, name=%s: %w", record.ID, record.Name, err)
 	}
 
 	return nil
 }
 
-func (d *DNSProvider) extractRecordName(fqdn, domain string) string {
-	name := dns01.UnFqdn(fqdn)
-	if idx := strings.Index(name, "."+domain); idx != -1 {
-		return name[:idx]
+// getZone returns the ArvanCloud zone for fqdn, querying the API only once
+// per fqdn: the result is cached until the matching CleanUp call forgets it.
+func (d *DNSProvider) getZone(fqdn string) (string, error) {
+	d.zonesMu.Lock()
+	defer d.zonesMu.Unlock()
+
+	if zone, ok := d.zones[fqdn]; ok {
+		return zone, nil
+	}
+
+	zone, err := d.client.FindZone(fqdn)
+	if err != nil {
+		return "", err
 	}
-	return name
+
+	d.zones[fqdn] = zone
+
+	return zone, nil
+}
+
+func (d *DNSProvider) forgetZone(fqdn string) {
+	d.zonesMu.Lock()
+	delete(d.zones, fqdn)
+	d.zonesMu.Unlock()
+}
+
+// extractRecordName returns fqdn's label relative to zone, e.g.
+// "_acme-challenge" for fqdn "_acme-challenge.foo.example.com." and zone
+// "foo.example.com".
+func extractRecordName(fqdn, zone string) string {
+	name := dns01.UnFqdn(fqdn)
+	return strings.TrimSuffix(name, "."+zone)
 }
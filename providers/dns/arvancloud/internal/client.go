@@ -0,0 +1,256 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// defaultBaseURL represents the API endpoint to call.
+const defaultBaseURL = "https://napi.arvancloud.com"
+
+const authHeader = "Authorization"
+
+// Client the ArvanCloud client.
+type Client struct {
+	HTTPClient *http.Client
+	BaseURL    string
+
+	apiKey string
+}
+
+// NewClient Creates a new ArvanCloud client.
+func NewClient(apiKey string) *Client {
+	return &Client{
+		HTTPClient: http.DefaultClient,
+		BaseURL:    defaultBaseURL,
+		apiKey:     apiKey,
+	}
+}
+
+// GetTxtRecord gets a TXT record.
+func (c *Client) GetTxtRecord(domain, name, value string) (*DNSRecord, error) {
+	records, err := c.getRecords(domain, name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, record := range records {
+		if equalsTXTRecord(record, name, value) {
+			return &record, nil
+		}
+	}
+
+	return nil, fmt.Errorf("could not find record: Domain: %s; Record: %s", domain, name)
+}
+
+// https://www.arvancloud.com/docs/api/cdn/4.0#operation/dns_records.list
+func (c *Client) getRecords(domain, search string) ([]DNSRecord, error) {
+	endpoint, err := c.createEndpoint("cdn", "4.0", "domains", domain, "dns-records")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create endpoint: %w", err)
+	}
+
+	if search != "" {
+		query := endpoint.Query()
+		query.Set("search", strings.ReplaceAll(search, "_", ""))
+		endpoint.RawQuery = query.Encode()
+	}
+
+	resp, err := c.do(http.MethodGet, endpoint.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not get records %s: Domain: %s; Status: %s; Body: %s",
+			search, domain, resp.Status, string(body))
+	}
+
+	response := &apiResponse{}
+	err = json.Unmarshal(body, response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode response body: %w", err)
+	}
+
+	var records []DNSRecord
+	err = json.Unmarshal(response.Data, &records)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode records: %w", err)
+	}
+
+	return records, nil
+}
+
+// CreateRecord creates a DNS record.
+// https://www.arvancloud.com/docs/api/cdn/4.0#operation/dns_records.create
+func (c *Client) CreateRecord(domain string, record DNSRecord) error {
+	reqBody, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	endpoint, err := c.createEndpoint("cdn", "4.0", "domains", domain, "dns-records")
+	if err != nil {
+		return fmt.Errorf("failed to create endpoint: %w", err)
+	}
+
+	resp, err := c.do(http.MethodPost, endpoint.String(), bytes.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("could not create record %s; Domain: %s; Status: %s; Body: %s", string(reqBody), domain, resp.Status, string(body))
+	}
+
+	return nil
+}
+
+// DeleteRecord deletes a DNS record.
+// https://www.arvancloud.com/docs/api/cdn/4.0#operation/dns_records.remove
+func (c *Client) DeleteRecord(domain, id string) error {
+	endpoint, err := c.createEndpoint("cdn", "4.0", "domains", domain, "dns-records", id)
+	if err != nil {
+		return fmt.Errorf("failed to create endpoint: %w", err)
+	}
+
+	resp, err := c.do(http.MethodDelete, endpoint.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("could not delete record %s; Domain: %s; Status: %s; Body: %s", id, domain, resp.Status, string(body))
+	}
+
+	return nil
+}
+
+// ListZones lists the domains (zones) configured in the ArvanCloud account.
+// https://www.arvancloud.com/docs/api/cdn/4.0#operation/domains.list
+func (c *Client) ListZones() ([]string, error) {
+	endpoint, err := c.createEndpoint("cdn", "4.0", "domains")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create endpoint: %w", err)
+	}
+
+	resp, err := c.do(http.MethodGet, endpoint.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not list domains: Status: %s; Body: %s", resp.Status, string(body))
+	}
+
+	response := &apiResponse{}
+	if err := json.Unmarshal(body, response); err != nil {
+		return nil, fmt.Errorf("failed to decode response body: %w", err)
+	}
+
+	var domains []domainInfo
+	if err := json.Unmarshal(response.Data, &domains); err != nil {
+		return nil, fmt.Errorf("failed to decode domains: %w", err)
+	}
+
+	zones := make([]string, 0, len(domains))
+	for _, d := range domains {
+		zones = append(zones, d.Domain)
+	}
+
+	return zones, nil
+}
+
+// FindZone returns the ArvanCloud zone (domain) that is the longest suffix
+// match for fqdn. A wildcard or delegated subzone (e.g. *.foo.example.com)
+// may be registered at ArvanCloud as "foo.example.com" rather than the
+// parent "example.com", so the zone can't be derived from the challenge
+// domain alone; it has to be looked up against the zones ArvanCloud knows
+// about.
+func (c *Client) FindZone(fqdn string) (string, error) {
+	zones, err := c.ListZones()
+	if err != nil {
+		return "", fmt.Errorf("failed to list zones: %w", err)
+	}
+
+	name := strings.TrimSuffix(fqdn, ".")
+
+	var best string
+	for _, zone := range zones {
+		if zone != name && !strings.HasSuffix(name, "."+zone) {
+			continue
+		}
+
+		if len(zone) > len(best) {
+			best = zone
+		}
+	}
+
+	if best == "" {
+		return "", fmt.Errorf("no matching zone found at ArvanCloud for fqdn %s", fqdn)
+	}
+
+	return best, nil
+}
+
+func (c *Client) do(method, endpoint string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set(authHeader, c.apiKey)
+
+	return c.HTTPClient.Do(req)
+}
+
+func (c *Client) createEndpoint(parts ...string) (*url.URL, error) {
+	baseURL, err := url.Parse(c.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, err := baseURL.Parse(path.Join(parts...))
+	if err != nil {
+		return nil, err
+	}
+
+	return endpoint, nil
+}
+
+func equalsTXTRecord(record DNSRecord, name, value string) bool {
+	return record.Type == "txt" && record.Name == name && record.Value.Text == value
+}
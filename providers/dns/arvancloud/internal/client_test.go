@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_FindZone(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+
+	const apiKey = "myKeyC"
+
+	mux.HandleFunc("/cdn/4.0/domains", func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.Error(rw, fmt.Sprintf("unsupported method: %s", req.Method), http.StatusMethodNotAllowed)
+			return
+		}
+
+		_, _ = rw.Write([]byte(`{"data":[{"domain":"example.com"},{"domain":"foo.example.com"}]}`))
+	})
+
+	client := NewClient(apiKey)
+	client.BaseURL = server.URL
+
+	testCases := []struct {
+		desc     string
+		fqdn     string
+		expected string
+	}{
+		{
+			desc:     "matches the longest suffix",
+			fqdn:     "_acme-challenge.foo.example.com.",
+			expected: "foo.example.com",
+		},
+		{
+			desc:     "falls back to the parent zone",
+			fqdn:     "_acme-challenge.bar.example.com.",
+			expected: "example.com",
+		},
+		{
+			desc:     "matches a zone exactly",
+			fqdn:     "example.com.",
+			expected: "example.com",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			zone, err := client.FindZone(test.fqdn)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, zone)
+		})
+	}
+}
+
+func TestClient_FindZone_noMatch(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+
+	mux.HandleFunc("/cdn/4.0/domains", func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(`{"data":[{"domain":"example.com"}]}`))
+	})
+
+	client := NewClient("myKeyD")
+	client.BaseURL = server.URL
+
+	_, err := client.FindZone("_acme-challenge.other.org.")
+	require.Error(t, err)
+}
@@ -0,0 +1,36 @@
+package internal
+
+import "encoding/json"
+
+type apiResponse struct {
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// DNSRecord a DNS record.
+type DNSRecord struct {
+	ID            string       `json:"id,omitempty"`
+	Type          string       `json:"type"`
+	Value         TxtValue     `json:"value,omitempty"`
+	Name          string       `json:"name,omitempty"`
+	TTL           int          `json:"ttl,omitempty"`
+	UpstreamHTTPS string       `json:"upstream_https,omitempty"`
+	IPFilterMode  IPFilterMode `json:"ip_filter_mode,omitempty"`
+}
+
+// TxtValue represents a TXT record value.
+type TxtValue struct {
+	Text string `json:"text,omitempty"`
+}
+
+// IPFilterMode a DNS ip_filter_mode.
+type IPFilterMode struct {
+	Count     string `json:"count,omitempty"`
+	Order     string `json:"order,omitempty"`
+	GeoFilter string `json:"geo_filter,omitempty"`
+}
+
+// domainInfo is one entry of the ArvanCloud domains (zones) listing.
+type domainInfo struct {
+	Domain string `json:"domain"`
+}
@@ -0,0 +1,249 @@
+package renewer
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	"github.com/go-acme/lego/v3/certcrypto"
+	"github.com/go-acme/lego/v3/certificate"
+	"github.com/go-acme/lego/v3/lego"
+	"github.com/go-acme/lego/v3/log"
+	"golang.org/x/sync/singleflight" // new direct dependency; run `go mod tidy` against this module's go.mod
+)
+
+// Result is the outcome of renewing a single domain as part of RenewAll.
+type Result struct {
+	Domain      string
+	Certificate *certificate.Resource
+	Err         error
+}
+
+// Renewer drives certificate renewal against a CertificatesStorage backend
+// independently of the CLI, so a Go server can embed it directly (including
+// as the backing store behind tls.Config.GetCertificate) instead of
+// shelling out to the lego binary.
+type Renewer struct {
+	Client    *lego.Client
+	Storage   CertificatesStorage
+	Threshold time.Duration
+
+	// obtainFunc, when set, replaces Client.Certificate.Obtain as the
+	// source of new certificates. Production callers leave it nil; tests
+	// use it to substitute a fake ACME client, since this package has no
+	// way to construct a real *lego.Client without a live ACME server.
+	obtainFunc func(certificate.ObtainRequest) (*certificate.Resource, error)
+
+	// obtainGroup deduplicates concurrent GetCertificate calls for the same
+	// domain, so a burst of TLS handshakes for a still-unobtained name
+	// triggers a single Obtain rather than one per handshake. The zero
+	// value is ready to use.
+	obtainGroup singleflight.Group
+
+	// renewGroup deduplicates concurrent background renewal checks kicked
+	// off by GetCertificate once a certificate is past Threshold, so a
+	// burst of handshakes for the same domain shares one in-flight Renew
+	// instead of each calling Client.Certificate.Obtain independently. The
+	// zero value is ready to use.
+	renewGroup singleflight.Group
+}
+
+// Renew checks whether domain's certificate is within Threshold of
+// expiring and, if so, obtains a replacement and saves it to Storage. It
+// returns a nil Resource (and nil error) when the certificate is not yet
+// due for renewal. The attempt honors any backoff recorded from a previous
+// failure and, on failure, persists the error and the next time a retry is
+// worth trying.
+func (r *Renewer) Renew(ctx context.Context, domain string) (*certificate.Resource, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return RenewOnce(domain, r.Storage, func() (*certificate.Resource, error) {
+		return r.renewDomain(domain)
+	})
+}
+
+func (r *Renewer) renewDomain(domain string) (*certificate.Resource, error) {
+	certificates, err := r.Storage.ReadCertificate(domain, ".crt")
+	if err != nil {
+		return nil, fmt.Errorf("error while loading the certificate for domain %s: %w", domain, err)
+	}
+	cert := certificates[0]
+
+	due, err := NeedRenewal(cert, domain, r.Threshold)
+	if err != nil {
+		return nil, err
+	}
+	if !due {
+		return nil, nil
+	}
+
+	timeLeft := cert.NotAfter.Sub(time.Now().UTC())
+	log.Infof("[%s] acme: Trying renewal with %d hours remaining", domain, int(timeLeft.Hours()))
+
+	request := certificate.ObtainRequest{
+		Domains: certcrypto.ExtractDomains(cert),
+		Bundle:  true,
+	}
+	certRes, err := r.obtainCertificate(request)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Storage.SaveResource(certRes)
+
+	return certRes, nil
+}
+
+// RenewAll walks every certificate known to Storage and renews the ones
+// within Threshold, continuing past individual failures so one broken
+// domain doesn't stop the rest.
+func (r *Renewer) RenewAll(ctx context.Context) ([]Result, error) {
+	domains, err := r.Storage.ListCertificates()
+	if err != nil {
+		return nil, fmt.Errorf("listing certificates: %w", err)
+	}
+
+	results := make([]Result, 0, len(domains))
+	for _, domain := range domains {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+
+		certRes, err := r.Renew(ctx, domain)
+		results = append(results, Result{Domain: domain, Certificate: certRes, Err: err})
+	}
+
+	return results, nil
+}
+
+// GetCertificate makes a Renewer a drop-in for tls.Config.GetCertificate:
+// it serves the certificate on file for hello's SNI name, obtaining one for
+// the first time if Storage doesn't have it yet, and renewing it in the
+// background once it is within Threshold of expiring.
+func (r *Renewer) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	domain := hello.ServerName
+	if domain == "" {
+		return nil, fmt.Errorf("renewer: no SNI server name in ClientHello")
+	}
+
+	if !r.Storage.ExistsFile(domain, ".crt") {
+		certRes, err := r.obtainOnce(domain)
+		if err != nil {
+			return nil, fmt.Errorf("renewer: obtaining initial certificate for %s: %w", domain, err)
+		}
+		return tlsCertificateFromResource(certRes)
+	}
+
+	r.renewInBackground(domain)
+
+	crtPEM, err := r.Storage.ReadFile(domain, ".crt")
+	if err != nil {
+		return nil, fmt.Errorf("renewer: reading certificate for %s: %w", domain, err)
+	}
+
+	keyPEM, err := r.Storage.ReadFile(domain, ".key")
+	if err != nil {
+		return nil, fmt.Errorf("renewer: reading private key for %s: %w", domain, err)
+	}
+
+	cert, err := tls.X509KeyPair(crtPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("renewer: parsing certificate for %s: %w", domain, err)
+	}
+
+	return &cert, nil
+}
+
+// renewInBackground kicks off a renewal check for domain without blocking
+// the calling handshake: the handshake is served whatever certificate is
+// currently on file (stale or not) while the check runs, and renewGroup
+// ensures concurrent handshakes for the same domain share one in-flight
+// Renew rather than each independently calling Client.Certificate.Obtain.
+func (r *Renewer) renewInBackground(domain string) {
+	resultCh := r.renewGroup.DoChan(domain, func() (interface{}, error) {
+		return r.Renew(context.Background(), domain)
+	})
+
+	go func() {
+		if result := <-resultCh; result.Err != nil {
+			log.Printf("[%s] renewer: background renewal check failed: %v", domain, result.Err)
+		}
+	}()
+}
+
+// obtainOnce obtains domain's first certificate through RenewOnce (so a
+// failure is recorded and backed off like any other renewal failure,
+// instead of retrying on every subsequent handshake) and through
+// obtainGroup (so concurrent handshakes for the same still-unobtained
+// domain share a single in-flight Obtain instead of each starting their
+// own).
+func (r *Renewer) obtainOnce(domain string) (*certificate.Resource, error) {
+	v, err, _ := r.obtainGroup.Do(domain, func() (interface{}, error) {
+		return RenewOnce(domain, r.Storage, func() (*certificate.Resource, error) {
+			return r.obtain(domain)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*certificate.Resource), nil
+}
+
+func (r *Renewer) obtain(domain string) (*certificate.Resource, error) {
+	request := certificate.ObtainRequest{
+		Domains: []string{domain},
+		Bundle:  true,
+	}
+
+	certRes, err := r.obtainCertificate(request)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Storage.SaveResource(certRes)
+
+	return certRes, nil
+}
+
+// obtainCertificate is the single place renewDomain and obtain call through
+// to actually request a certificate, so obtainFunc only needs overriding in
+// one spot for tests to substitute a fake ACME client.
+func (r *Renewer) obtainCertificate(request certificate.ObtainRequest) (*certificate.Resource, error) {
+	if r.obtainFunc != nil {
+		return r.obtainFunc(request)
+	}
+	return r.Client.Certificate.Obtain(request)
+}
+
+func tlsCertificateFromResource(certRes *certificate.Resource) (*tls.Certificate, error) {
+	cert, err := tls.X509KeyPair(certRes.Certificate, certRes.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("renewer: parsing obtained certificate for %s: %w", certRes.Domain, err)
+	}
+	return &cert, nil
+}
+
+// NeedRenewal reports whether cert is within threshold of expiring. It
+// returns an error rather than exiting the process on a malformed
+// certificate bundle, so both the library and the CLI can share one
+// implementation.
+func NeedRenewal(cert *x509.Certificate, domain string, threshold time.Duration) (bool, error) {
+	if cert.IsCA {
+		return false, fmt.Errorf("[%s] certificate bundle starts with a CA certificate", domain)
+	}
+
+	if threshold >= 0 {
+		timeLeft := time.Until(cert.NotAfter)
+		if timeLeft > threshold {
+			log.Printf("[%s] The certificate expires in %s, the renewal threshold is %s: no renewal.",
+				domain, timeLeft.Round(time.Hour), threshold)
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
@@ -0,0 +1,161 @@
+package renewer
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-acme/lego/v3/certificate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNeedRenewal(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		notAfter  time.Time
+		isCA      bool
+		threshold time.Duration
+		due       bool
+		wantErr   bool
+	}{
+		{
+			desc:      "CA certificate is rejected",
+			notAfter:  time.Now().Add(30 * 24 * time.Hour),
+			isCA:      true,
+			threshold: 10 * 24 * time.Hour,
+			wantErr:   true,
+		},
+		{
+			desc:      "far from expiry, no renewal",
+			notAfter:  time.Now().Add(60 * 24 * time.Hour),
+			threshold: 10 * 24 * time.Hour,
+			due:       false,
+		},
+		{
+			desc:      "within threshold, needs renewal",
+			notAfter:  time.Now().Add(5 * 24 * time.Hour),
+			threshold: 10 * 24 * time.Hour,
+			due:       true,
+		},
+		{
+			desc:      "negative threshold always renews",
+			notAfter:  time.Now().Add(365 * 24 * time.Hour),
+			threshold: -1,
+			due:       true,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			cert := generateTestCertificate(t, test.notAfter, test.isCA)
+
+			due, err := NeedRenewal(cert, "example.com", test.threshold)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.due, due)
+		})
+	}
+}
+
+// TestRenewer_GetCertificate_dedupsConcurrentBackgroundRenewals exercises the
+// race this package used to have: a burst of handshakes for a domain past
+// Threshold each independently calling Client.Certificate.Obtain. It fails
+// either by timing out (GetCertificate blocking handshakes on the renewal)
+// or by counting more than one Obtain call (no dedup).
+func TestRenewer_GetCertificate_dedupsConcurrentBackgroundRenewals(t *testing.T) {
+	domain := "example.com"
+
+	storage := NewFileCertificatesStorage(t.TempDir(), false, "")
+	storage.CreateRootFolder()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	cert := generateTestCertificateForKey(t, key, time.Now().Add(time.Hour), false)
+
+	storage.SaveResource(&certificate.Resource{
+		Domain:      domain,
+		Certificate: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}),
+		PrivateKey:  pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+	})
+
+	var obtainCalls int32
+	release := make(chan struct{})
+
+	rnw := &Renewer{
+		Storage:   storage,
+		Threshold: 24 * time.Hour, // well past the cert's 1h validity, forces renewal
+		obtainFunc: func(certificate.ObtainRequest) (*certificate.Resource, error) {
+			atomic.AddInt32(&obtainCalls, 1)
+			<-release
+			return nil, errors.New("simulated ACME outage")
+		},
+	}
+
+	const concurrency = 10
+	hello := &tls.ClientHelloInfo{ServerName: domain}
+
+	done := make(chan struct{}, concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			tlsCert, err := rnw.GetCertificate(hello)
+			assert.NoError(t, err)
+			assert.NotNil(t, tlsCert)
+			done <- struct{}{}
+		}()
+	}
+
+	for i := 0; i < concurrency; i++ {
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatal("GetCertificate blocked on the background renewal instead of serving the cached certificate")
+		}
+	}
+
+	close(release)
+
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&obtainCalls) >= 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&obtainCalls))
+}
+
+func generateTestCertificate(t *testing.T, notAfter time.Time, isCA bool) *x509.Certificate {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	return generateTestCertificateForKey(t, key, notAfter, isCA)
+}
+
+func generateTestCertificateForKey(t *testing.T, key *rsa.PrivateKey, notAfter time.Time, isCA bool) *x509.Certificate {
+	t.Helper()
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "example.com"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              notAfter,
+		IsCA:                  isCA,
+		BasicConstraintsValid: isCA,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return cert
+}
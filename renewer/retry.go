@@ -0,0 +1,128 @@
+package renewer
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/go-acme/lego/v3/acme"
+	"github.com/go-acme/lego/v3/certificate"
+	"github.com/go-acme/lego/v3/log"
+)
+
+const (
+	backoffBase   = time.Minute
+	backoffFactor = 2.0
+	backoffCap    = 24 * time.Hour
+)
+
+// terminalACMEErrors are RFC 8555 error types that will not resolve on
+// their own: retrying with the same CSR/account only wastes requests.
+var terminalACMEErrors = map[string]bool{
+	"urn:ietf:params:acme:error:badCSR":              true,
+	"urn:ietf:params:acme:error:rejectedIdentifier":  true,
+	"urn:ietf:params:acme:error:accountDoesNotExist": true,
+	"urn:ietf:params:acme:error:unauthorized":        true,
+	"urn:ietf:params:acme:error:malformed":           true,
+}
+
+// BackoffError is returned by RenewOnce when domain is still inside a
+// backoff window recorded from a previous failed attempt: obtain was not
+// called this pass, so callers (in particular the --daemon metrics) should
+// not count it as a failed renewal attempt.
+type BackoffError struct {
+	Domain        string
+	NextAttemptAt time.Time
+	Attempts      int
+	LastError     string
+}
+
+func (e *BackoffError) Error() string {
+	return fmt.Sprintf("[%s] skipping renewal, still backing off until %s after %d failed attempt(s): %s",
+		e.Domain, e.NextAttemptAt.Format(time.RFC3339), e.Attempts, e.LastError)
+}
+
+// RenewOnce wraps an obtain attempt for domain with resilience: it honors
+// any backoff recorded from a previous failed attempt, and on failure
+// persists the error and the next time a retry is worth trying, so callers
+// (in particular the --daemon scheduler) never hammer the ACME server.
+func RenewOnce(domain string, certsStorage CertificatesStorage, obtain func() (*certificate.Resource, error)) (*certificate.Resource, error) {
+	meta, err := certsStorage.ReadMeta(domain)
+	if err != nil {
+		log.Printf("[%s] unable to read renewal state, proceeding without it: %v", domain, err)
+	}
+
+	if !meta.NextAttemptAt.IsZero() && time.Now().UTC().Before(meta.NextAttemptAt) {
+		return nil, &BackoffError{
+			Domain:        domain,
+			NextAttemptAt: meta.NextAttemptAt,
+			Attempts:      meta.Attempts,
+			LastError:     meta.LastError,
+		}
+	}
+
+	certRes, err := obtain()
+	if err != nil {
+		meta.Attempts++
+		meta.LastError = err.Error()
+		meta.NextAttemptAt = time.Now().UTC().Add(nextBackoff(meta.Attempts, err))
+
+		if saveErr := certsStorage.SaveMeta(domain, meta); saveErr != nil {
+			log.Printf("[%s] unable to persist renewal backoff state: %v", domain, saveErr)
+		}
+
+		return nil, err
+	}
+
+	if meta.Attempts > 0 {
+		if saveErr := certsStorage.SaveMeta(domain, ResourceMeta{}); saveErr != nil {
+			log.Printf("[%s] unable to clear renewal backoff state: %v", domain, saveErr)
+		}
+	}
+
+	return certRes, nil
+}
+
+// nextBackoff computes the delay before the next renewal attempt: a
+// terminal error is parked for a full day (an operator fix, not time, is
+// what will resolve it), and everything else backs off exponentially (base
+// 1m, factor 2, capped at 24h) with full jitter.
+//
+// Rate-limit errors (http.StatusTooManyRequests) fall back to the same
+// exponential schedule rather than honoring the server's Retry-After: the
+// error this package sees out of Client.Certificate.Obtain is an
+// *acme.ProblemDetails with only Type/HTTPStatus, not the underlying HTTP
+// response, so there's no header to read. Revisit if lego starts
+// surfacing it on the error.
+func nextBackoff(attempt int, err error) time.Duration {
+	if isTerminalError(err) {
+		return backoffCap
+	}
+
+	delay := float64(backoffBase) * math.Pow(backoffFactor, float64(attempt-1))
+	if delay > float64(backoffCap) {
+		delay = float64(backoffCap)
+	}
+
+	// Full jitter: https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/
+	return time.Duration(rand.Int63n(int64(delay)) + 1) //nolint:gosec // scheduling jitter, not security sensitive
+}
+
+// isTerminalError reports whether err is retryable (network hiccup, 5xx,
+// rate limiting) or terminal (the request itself is invalid and needs an
+// operator fix).
+func isTerminalError(err error) bool {
+	var problem *acme.ProblemDetails
+	if errors.As(err, &problem) {
+		if terminalACMEErrors[problem.Type] {
+			return true
+		}
+		return problem.HTTPStatus != 0 && problem.HTTPStatus < http.StatusInternalServerError &&
+			problem.HTTPStatus != http.StatusTooManyRequests
+	}
+
+	return false
+}
@@ -0,0 +1,70 @@
+package renewer
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-acme/lego/v3/acme"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsTerminalError(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		err      error
+		terminal bool
+	}{
+		{
+			desc:     "known terminal ACME error type",
+			err:      &acme.ProblemDetails{Type: "urn:ietf:params:acme:error:rejectedIdentifier"},
+			terminal: true,
+		},
+		{
+			desc:     "4xx status without a known terminal type is still terminal",
+			err:      &acme.ProblemDetails{HTTPStatus: http.StatusBadRequest},
+			terminal: true,
+		},
+		{
+			desc:     "rate limiting is retryable, not terminal",
+			err:      &acme.ProblemDetails{HTTPStatus: http.StatusTooManyRequests},
+			terminal: false,
+		},
+		{
+			desc:     "5xx status is retryable, not terminal",
+			err:      &acme.ProblemDetails{HTTPStatus: http.StatusServiceUnavailable},
+			terminal: false,
+		},
+		{
+			desc:     "non-ACME error is retryable, not terminal",
+			err:      errors.New("connection reset"),
+			terminal: false,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			assert.Equal(t, test.terminal, isTerminalError(test.err))
+		})
+	}
+}
+
+func TestNextBackoff(t *testing.T) {
+	t.Run("terminal error is parked for the backoff cap", func(t *testing.T) {
+		err := &acme.ProblemDetails{Type: "urn:ietf:params:acme:error:malformed"}
+		assert.Equal(t, backoffCap, nextBackoff(1, err))
+	})
+
+	t.Run("exponential backoff is capped", func(t *testing.T) {
+		delay := nextBackoff(100, errors.New("network hiccup"))
+		assert.LessOrEqual(t, delay, backoffCap)
+		assert.Greater(t, delay, time.Duration(0))
+	})
+
+	t.Run("first attempt backs off from the base delay", func(t *testing.T) {
+		delay := nextBackoff(1, errors.New("network hiccup"))
+		assert.LessOrEqual(t, delay, backoffBase)
+		assert.Greater(t, delay, time.Duration(0))
+	})
+}
@@ -0,0 +1,392 @@
+// Package renewer provides a programmatic certificate renewal loop built on
+// the same CertificatesStorage abstraction as the lego CLI, so a Go server
+// can embed ACME certificate management directly (including drop-in use as
+// tls.Config.GetCertificate) instead of shelling out to the lego binary.
+package renewer
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-acme/lego/v3/certcrypto"
+	"github.com/go-acme/lego/v3/certificate"
+	"github.com/go-acme/lego/v3/log"
+	"golang.org/x/net/idna"
+)
+
+const (
+	baseCertificatesFolderName = "certificates"
+	baseArchivesFolderName     = "archives"
+)
+
+const filePerm os.FileMode = 0600
+
+// ResourceMeta carries the bookkeeping a CertificatesStorage backend needs
+// alongside the raw certificate material, so it can answer "is this
+// certificate still current for this account" without re-parsing the
+// certificate itself, and so a failed renewal can back off instead of
+// hammering the ACME server on every invocation.
+type ResourceMeta struct {
+	IssuerURL   string
+	AccountHash string
+
+	// Attempts, LastError and NextAttemptAt track a renewal that has
+	// failed at least once. Attempts resets to 0 on success.
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time
+}
+
+// CertificatesStorage stores and retrieves the certificates obtained by lego.
+// FileCertificatesStorage writes PEM/JSON files to disk (the historical
+// behavior); SQLCertificatesStorage is a database/sql backed alternative so
+// several lego nodes can share one renewal state.
+type CertificatesStorage interface {
+	// ReadCertificate reads and parses the certificate (and any chained
+	// issuer certificates) stored for domain under extension (e.g. ".crt").
+	ReadCertificate(domain, extension string) ([]*x509.Certificate, error)
+
+	// ReadFile reads the raw bytes stored for domain under extension
+	// (e.g. ".key").
+	ReadFile(domain, extension string) ([]byte, error)
+
+	// ExistsFile reports whether something is stored for domain under extension.
+	ExistsFile(domain, extension string) bool
+
+	// GetFileName returns a backend-specific identifier for domain's file
+	// under extension, used to populate the renew-hook environment variables.
+	GetFileName(domain, extension string) string
+
+	// SaveResource persists an obtained certificate resource.
+	SaveResource(certRes *certificate.Resource)
+
+	// SaveResourceCtx persists an obtained certificate resource together
+	// with meta, and can be cancelled through ctx. The SQL backend uses it
+	// to populate the issuer/account columns; the file backend writes meta
+	// to a .meta.json sidecar next to the certificate.Resource's .json file.
+	SaveResourceCtx(ctx context.Context, certRes *certificate.Resource, meta ResourceMeta) error
+
+	// ListCertificates returns the domain name of every certificate known
+	// to the backend, so a renewal loop can iterate all of them without
+	// knowing how they are stored.
+	ListCertificates() ([]string, error)
+
+	// ReadMeta returns the ResourceMeta stored for domain, or a zero-value
+	// ResourceMeta if none has been saved yet.
+	ReadMeta(domain string) (ResourceMeta, error)
+
+	// SaveMeta persists meta for domain, independently of SaveResourceCtx,
+	// so renewal backoff state can be recorded even when an attempt fails
+	// and no new certificate.Resource exists.
+	SaveMeta(domain string, meta ResourceMeta) error
+
+	// ReadResource returns the full certificate.Resource saved for domain.
+	ReadResource(domain string) certificate.Resource
+
+	// CreateRootFolder ensures the backend's active storage location exists,
+	// creating it if needed.
+	CreateRootFolder()
+
+	// CreateArchiveFolder ensures the backend's archive storage location
+	// exists, creating it if needed.
+	CreateArchiveFolder()
+
+	// GetRootPath returns a backend-specific identifier for where
+	// certificates are stored (a directory for the file backend, the data
+	// source name for the SQL backend), used for diagnostic output.
+	GetRootPath() string
+
+	// MoveToArchive removes domain's certificate from active storage,
+	// retaining it in the backend's archive.
+	MoveToArchive(domain string) error
+}
+
+// FileCertificatesStorage is the historical on-disk CertificatesStorage: one
+// PEM/JSON file per domain and extension under rootPath.
+//
+// rootPath:
+//
+//	./.lego/certificates/
+//	     │      └── root certificates directory
+//	     └── "path" option
+//
+// archivePath:
+//
+//	./.lego/archives/
+//	     │      └── archived certificates directory
+//	     └── "path" option
+type FileCertificatesStorage struct {
+	rootPath    string
+	archivePath string
+	pem         bool
+	filename    string // Deprecated
+}
+
+// NewFileCertificatesStorage creates a FileCertificatesStorage rooted at
+// basePath, i.e. basePath/certificates and basePath/archives.
+func NewFileCertificatesStorage(basePath string, pem bool, filename string) *FileCertificatesStorage {
+	return &FileCertificatesStorage{
+		rootPath:    filepath.Join(basePath, baseCertificatesFolderName),
+		archivePath: filepath.Join(basePath, baseArchivesFolderName),
+		pem:         pem,
+		filename:    filename,
+	}
+}
+
+func (s *FileCertificatesStorage) CreateRootFolder() {
+	if err := createNonExistingFolder(s.rootPath); err != nil {
+		log.Fatalf("Could not check/create path: %v", err)
+	}
+}
+
+func (s *FileCertificatesStorage) CreateArchiveFolder() {
+	if err := createNonExistingFolder(s.archivePath); err != nil {
+		log.Fatalf("Could not check/create path: %v", err)
+	}
+}
+
+func (s *FileCertificatesStorage) GetRootPath() string {
+	return s.rootPath
+}
+
+func (s *FileCertificatesStorage) SaveResource(certRes *certificate.Resource) {
+	domain := certRes.Domain
+
+	// We store the certificate, private key and metadata in different files
+	// as web servers would not be able to work with a combined file.
+	err := s.WriteFile(domain, ".crt", certRes.Certificate)
+	if err != nil {
+		log.Fatalf("Unable to save Certificate for domain %s\n\t%v", domain, err)
+	}
+
+	if certRes.IssuerCertificate != nil {
+		err = s.WriteFile(domain, ".issuer.crt", certRes.IssuerCertificate)
+		if err != nil {
+			log.Fatalf("Unable to save IssuerCertificate for domain %s\n\t%v", domain, err)
+		}
+	}
+
+	if certRes.PrivateKey != nil {
+		// if we were given a CSR, we don't know the private key
+		err = s.WriteFile(domain, ".key", certRes.PrivateKey)
+		if err != nil {
+			log.Fatalf("Unable to save PrivateKey for domain %s\n\t%v", domain, err)
+		}
+
+		if s.pem {
+			err = s.WriteFile(domain, ".pem", bytes.Join([][]byte{certRes.Certificate, certRes.PrivateKey}, nil))
+			if err != nil {
+				log.Fatalf("Unable to save Certificate and PrivateKey in .pem for domain %s\n\t%v", domain, err)
+			}
+		}
+	} else if s.pem {
+		// we don't have the private key; can't write the .pem file
+		log.Fatalf("Unable to save pem without private key for domain %s\n\t%v; are you using a CSR?", domain, err)
+	}
+
+	jsonBytes, err := json.MarshalIndent(certRes, "", "\t")
+	if err != nil {
+		log.Fatalf("Unable to marshal CertResource for domain %s\n\t%v", domain, err)
+	}
+
+	err = s.WriteFile(domain, ".json", jsonBytes)
+	if err != nil {
+		log.Fatalf("Unable to save CertResource for domain %s\n\t%v", domain, err)
+	}
+}
+
+// SaveResourceCtx persists certRes like SaveResource, and additionally
+// stamps the .meta.json sidecar with meta so the next renewal pass can tell
+// which issuer/account produced it.
+func (s *FileCertificatesStorage) SaveResourceCtx(ctx context.Context, certRes *certificate.Resource, meta ResourceMeta) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.SaveResource(certRes)
+
+	return s.SaveMeta(certRes.Domain, meta)
+}
+
+// ReadMeta returns the ResourceMeta stored for domain's .meta.json sidecar,
+// or a zero-value ResourceMeta if nothing has been saved yet.
+func (s *FileCertificatesStorage) ReadMeta(domain string) (ResourceMeta, error) {
+	if !s.ExistsFile(domain, ".meta.json") {
+		return ResourceMeta{}, nil
+	}
+
+	raw, err := s.ReadFile(domain, ".meta.json")
+	if err != nil {
+		return ResourceMeta{}, fmt.Errorf("reading metadata for domain %s: %w", domain, err)
+	}
+
+	var meta ResourceMeta
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return ResourceMeta{}, fmt.Errorf("unmarshaling metadata for domain %s: %w", domain, err)
+	}
+
+	return meta, nil
+}
+
+// SaveMeta writes meta to domain's .meta.json sidecar.
+func (s *FileCertificatesStorage) SaveMeta(domain string, meta ResourceMeta) error {
+	jsonBytes, err := json.MarshalIndent(meta, "", "\t")
+	if err != nil {
+		return fmt.Errorf("unable to marshal metadata for domain %s: %w", domain, err)
+	}
+
+	return s.WriteFile(domain, ".meta.json", jsonBytes)
+}
+
+func (s *FileCertificatesStorage) ReadResource(domain string) certificate.Resource {
+	raw, err := s.ReadFile(domain, ".json")
+	if err != nil {
+		log.Fatalf("Error while loading the meta data for domain %s\n\t%v", domain, err)
+	}
+
+	var resource certificate.Resource
+	if err = json.Unmarshal(raw, &resource); err != nil {
+		log.Fatalf("Error while marshaling the meta data for domain %s\n\t%v", domain, err)
+	}
+
+	return resource
+}
+
+func (s *FileCertificatesStorage) ExistsFile(domain, extension string) bool {
+	filePath := s.GetFileName(domain, extension)
+
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		return false
+	} else if err != nil {
+		log.Fatal(err)
+	}
+	return true
+}
+
+func (s *FileCertificatesStorage) ReadFile(domain, extension string) ([]byte, error) {
+	return ioutil.ReadFile(s.GetFileName(domain, extension))
+}
+
+func (s *FileCertificatesStorage) GetFileName(domain, extension string) string {
+	filename := sanitizedDomain(domain) + extension
+	return filepath.Join(s.rootPath, filename)
+}
+
+func (s *FileCertificatesStorage) ReadCertificate(domain, extension string) ([]*x509.Certificate, error) {
+	content, err := s.ReadFile(domain, extension)
+	if err != nil {
+		return nil, err
+	}
+
+	// The input may be a bundle or a single certificate.
+	return certcrypto.ParsePEMBundle(content)
+}
+
+func (s *FileCertificatesStorage) WriteFile(domain, extension string, data []byte) error {
+	var baseFileName string
+	if s.filename != "" {
+		baseFileName = s.filename
+	} else {
+		baseFileName = sanitizedDomain(domain)
+	}
+
+	filePath := filepath.Join(s.rootPath, baseFileName+extension)
+
+	return ioutil.WriteFile(filePath, data, filePerm)
+}
+
+func (s *FileCertificatesStorage) MoveToArchive(domain string) error {
+	matches, err := filepath.Glob(filepath.Join(s.rootPath, sanitizedDomain(domain)+".*"))
+	if err != nil {
+		return err
+	}
+
+	for _, oldFile := range matches {
+		date := strconv.FormatInt(time.Now().Unix(), 10)
+		filename := date + "." + filepath.Base(oldFile)
+		newFile := filepath.Join(s.archivePath, filename)
+
+		err = os.Rename(oldFile, newFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ListCertificates returns the domain name of every certificate under rootPath.
+func (s *FileCertificatesStorage) ListCertificates() ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(s.rootPath, "*.crt"))
+	if err != nil {
+		return nil, err
+	}
+
+	var domains []string
+	for _, match := range matches {
+		base := strings.TrimSuffix(filepath.Base(match), ".crt")
+		if strings.HasSuffix(base, ".issuer") {
+			continue
+		}
+
+		domain, err := s.domainFromResourceFile(base)
+		if err != nil {
+			return nil, fmt.Errorf("recovering domain name for %s: %w", base, err)
+		}
+		domains = append(domains, domain)
+	}
+
+	return domains, nil
+}
+
+// domainFromResourceFile recovers the original domain name for a
+// certificate whose sanitized filename base is sanitizedBase, by reading it
+// back out of the .json resource sidecar saved alongside the certificate.
+// sanitizedDomain is lossy (e.g. "*" becomes "_"), so the filename itself
+// cannot be trusted to round-trip a wildcard domain.
+func (s *FileCertificatesStorage) domainFromResourceFile(sanitizedBase string) (string, error) {
+	raw, err := ioutil.ReadFile(filepath.Join(s.rootPath, sanitizedBase+".json"))
+	if err != nil {
+		return "", err
+	}
+
+	var resource struct {
+		Domain string `json:"domain"`
+	}
+	if err := json.Unmarshal(raw, &resource); err != nil {
+		return "", err
+	}
+	if resource.Domain == "" {
+		return "", fmt.Errorf("no domain recorded in %s.json", sanitizedBase)
+	}
+
+	return resource.Domain, nil
+}
+
+// sanitizedDomain Make sure no funny chars are in the cert names (like wildcards ;)).
+func sanitizedDomain(domain string) string {
+	safe, err := idna.ToASCII(strings.Replace(domain, "*", "_", -1))
+	if err != nil {
+		log.Fatal(err)
+	}
+	return safe
+}
+
+func createNonExistingFolder(path string) error {
+	_, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return os.MkdirAll(path, 0o700)
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,299 @@
+package renewer
+
+import (
+	"context"
+	"crypto/x509"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-acme/lego/v3/certcrypto"
+	"github.com/go-acme/lego/v3/certificate"
+	"github.com/go-acme/lego/v3/log"
+
+	// Pure Go SQLite driver, so lego keeps building without cgo. New direct
+	// dependency; run `go mod tidy` against this module's go.mod.
+	_ "modernc.org/sqlite"
+)
+
+// Certificate lifecycle states, tracked so operators coordinating renewals
+// across a cluster can tell a certificate that is still in use from one
+// that is pending cleanup.
+const (
+	certStateAdded   = "added"
+	certStateNormal  = "normal"
+	certStateRemoved = "removed"
+)
+
+// SQLCertificatesStorage is a database/sql backed CertificatesStorage. It
+// keys rows by (account, domain) so several lego nodes can point at the
+// same database and coordinate which certificates they have already handled.
+type SQLCertificatesStorage struct {
+	db             *sql.DB
+	dataSourceName string
+	account        string
+}
+
+// sqliteBusyTimeout bounds how long a writer waits on SQLITE_BUSY before
+// giving up, instead of failing immediately. The daemon's worker pool
+// (renewDaemonWorkers) can drive several concurrent writes against the same
+// file, and SQLite only ever allows one writer at a time.
+const sqliteBusyTimeout = 5 * time.Second
+
+// NewSQLCertificatesStorage opens (and, if needed, initializes) a SQL
+// certificates store at dataSourceName, scoped to account.
+func NewSQLCertificatesStorage(dataSourceName, account string) (*SQLCertificatesStorage, error) {
+	db, err := sql.Open("sqlite", fmt.Sprintf("%s?_pragma=busy_timeout(%d)", dataSourceName, sqliteBusyTimeout.Milliseconds()))
+	if err != nil {
+		return nil, fmt.Errorf("opening sqlite storage: %w", err)
+	}
+
+	// modernc.org/sqlite has no per-connection locking of its own: with
+	// more than one open connection, concurrent writers from the same
+	// process hit the same SQLITE_BUSY risk as separate processes would.
+	// Serializing through one connection makes busy_timeout sufficient.
+	db.SetMaxOpenConns(1)
+
+	const schema = `
+CREATE TABLE IF NOT EXISTS certificates (
+	account         TEXT NOT NULL,
+	domain          TEXT NOT NULL,
+	state           TEXT NOT NULL DEFAULT 'added',
+	cert_pem        BLOB NOT NULL,
+	key_pem         BLOB,
+	issuer_pem      BLOB,
+	resource_json   TEXT,
+	meta_json       TEXT,
+	next_attempt_at INTEGER,
+	updated_at      INTEGER NOT NULL,
+	PRIMARY KEY (account, domain)
+)`
+
+	if _, err := db.Exec(schema); err != nil {
+		return nil, fmt.Errorf("creating certificates table: %w", err)
+	}
+
+	return &SQLCertificatesStorage{db: db, dataSourceName: dataSourceName, account: account}, nil
+}
+
+// CreateRootFolder is a no-op for the SQL backend: the certificates table is
+// created by NewSQLCertificatesStorage, so there is no directory to create.
+func (s *SQLCertificatesStorage) CreateRootFolder() {}
+
+// CreateArchiveFolder is a no-op for the SQL backend: archived certificates
+// stay in the same table, distinguished by state (see MoveToArchive).
+func (s *SQLCertificatesStorage) CreateArchiveFolder() {}
+
+// GetRootPath returns the data source name the storage was opened with,
+// since the SQL backend has no on-disk certificates directory to report.
+func (s *SQLCertificatesStorage) GetRootPath() string {
+	return s.dataSourceName
+}
+
+// MoveToArchive marks domain's row as removed rather than physically moving
+// files: the certificate material is retained for audit, but ExistsFile and
+// ListCertificates stop surfacing it as active.
+func (s *SQLCertificatesStorage) MoveToArchive(domain string) error {
+	_, err := s.db.Exec(`UPDATE certificates SET state = ?, updated_at = ? WHERE account = ? AND domain = ?`,
+		certStateRemoved, time.Now().UTC().Unix(), s.account, domain)
+	if err != nil {
+		return fmt.Errorf("archiving certificate for domain %s: %w", domain, err)
+	}
+	return nil
+}
+
+func (s *SQLCertificatesStorage) GetFileName(domain, extension string) string {
+	return fmt.Sprintf("sqlite:%s/%s%s", s.account, domain, extension)
+}
+
+// ExistsFile reports whether a certificate has actually been obtained for
+// domain; the extension is ignored, so ".key" reports the same as ".crt".
+// It excludes rows created only to record renewal backoff state
+// (SaveMeta inserts those with an empty cert_pem), since those don't carry
+// a usable certificate yet.
+func (s *SQLCertificatesStorage) ExistsFile(domain, _ string) bool {
+	var count int
+	row := s.db.QueryRow(
+		`SELECT COUNT(*) FROM certificates WHERE account = ? AND domain = ? AND state != ? AND length(cert_pem) > 0`,
+		s.account, domain, certStateRemoved)
+	if err := row.Scan(&count); err != nil {
+		return false
+	}
+	return count > 0
+}
+
+func (s *SQLCertificatesStorage) ReadFile(domain, extension string) ([]byte, error) {
+	column, err := columnForExtension(extension)
+	if err != nil {
+		return nil, err
+	}
+
+	var content []byte
+	row := s.db.QueryRow(fmt.Sprintf(`SELECT %s FROM certificates WHERE account = ? AND domain = ?`, column), s.account, domain)
+	if err := row.Scan(&content); err != nil {
+		return nil, fmt.Errorf("reading %s for domain %s: %w", extension, domain, err)
+	}
+
+	return content, nil
+}
+
+func (s *SQLCertificatesStorage) ReadCertificate(domain, extension string) ([]*x509.Certificate, error) {
+	content, err := s.ReadFile(domain, extension)
+	if err != nil {
+		return nil, err
+	}
+
+	return certcrypto.ParsePEMBundle(content)
+}
+
+func (s *SQLCertificatesStorage) SaveResource(certRes *certificate.Resource) {
+	_ = s.SaveResourceCtx(context.Background(), certRes, ResourceMeta{})
+}
+
+func (s *SQLCertificatesStorage) SaveResourceCtx(ctx context.Context, certRes *certificate.Resource, meta ResourceMeta) error {
+	metaJSON, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("marshaling metadata for domain %s: %w", certRes.Domain, err)
+	}
+
+	resourceJSON, err := json.Marshal(certRes)
+	if err != nil {
+		return fmt.Errorf("marshaling resource for domain %s: %w", certRes.Domain, err)
+	}
+
+	const upsert = `
+INSERT INTO certificates (account, domain, state, cert_pem, key_pem, issuer_pem, resource_json, meta_json, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (account, domain) DO UPDATE SET
+	state         = excluded.state,
+	cert_pem      = excluded.cert_pem,
+	key_pem       = excluded.key_pem,
+	issuer_pem    = excluded.issuer_pem,
+	resource_json = excluded.resource_json,
+	meta_json     = excluded.meta_json,
+	updated_at    = excluded.updated_at`
+
+	_, err = s.db.ExecContext(ctx, upsert,
+		s.account, certRes.Domain, certStateNormal,
+		certRes.Certificate, certRes.PrivateKey, certRes.IssuerCertificate,
+		string(resourceJSON), string(metaJSON), time.Now().UTC().Unix())
+	if err != nil {
+		return fmt.Errorf("saving certificate for domain %s: %w", certRes.Domain, err)
+	}
+
+	return nil
+}
+
+// ReadResource returns the full certificate.Resource saved for domain.
+func (s *SQLCertificatesStorage) ReadResource(domain string) certificate.Resource {
+	var resourceJSON sql.NullString
+	row := s.db.QueryRow(`SELECT resource_json FROM certificates WHERE account = ? AND domain = ?`, s.account, domain)
+	if err := row.Scan(&resourceJSON); err != nil {
+		log.Fatalf("Error while loading the meta data for domain %s\n\t%v", domain, err)
+	}
+
+	var resource certificate.Resource
+	if resourceJSON.Valid && resourceJSON.String != "" {
+		if err := json.Unmarshal([]byte(resourceJSON.String), &resource); err != nil {
+			log.Fatalf("Error while marshaling the meta data for domain %s\n\t%v", domain, err)
+		}
+	}
+
+	return resource
+}
+
+// ListCertificates returns the domain name of every certificate that has
+// actually been obtained (see ExistsFile for why length(cert_pem) is part of
+// the filter: a row can exist purely to record renewal backoff state for a
+// domain that has never been successfully obtained).
+func (s *SQLCertificatesStorage) ListCertificates() ([]string, error) {
+	rows, err := s.db.Query(
+		`SELECT domain FROM certificates WHERE account = ? AND state != ? AND length(cert_pem) > 0`,
+		s.account, certStateRemoved)
+	if err != nil {
+		return nil, fmt.Errorf("listing certificates: %w", err)
+	}
+	defer rows.Close()
+
+	var domains []string
+	for rows.Next() {
+		var domain string
+		if err := rows.Scan(&domain); err != nil {
+			return nil, err
+		}
+		domains = append(domains, domain)
+	}
+
+	return domains, rows.Err()
+}
+
+// ReadMeta returns the ResourceMeta stored for domain, or a zero-value
+// ResourceMeta if the row doesn't exist yet (e.g. a renewal that has never
+// succeeded or failed before).
+func (s *SQLCertificatesStorage) ReadMeta(domain string) (ResourceMeta, error) {
+	var metaJSON sql.NullString
+	var nextAttemptAt sql.NullInt64
+
+	row := s.db.QueryRow(`SELECT meta_json, next_attempt_at FROM certificates WHERE account = ? AND domain = ?`, s.account, domain)
+	if err := row.Scan(&metaJSON, &nextAttemptAt); err != nil {
+		if err == sql.ErrNoRows {
+			return ResourceMeta{}, nil
+		}
+		return ResourceMeta{}, fmt.Errorf("reading metadata for domain %s: %w", domain, err)
+	}
+
+	var meta ResourceMeta
+	if metaJSON.Valid && metaJSON.String != "" {
+		if err := json.Unmarshal([]byte(metaJSON.String), &meta); err != nil {
+			return ResourceMeta{}, fmt.Errorf("unmarshaling metadata for domain %s: %w", domain, err)
+		}
+	}
+	if nextAttemptAt.Valid {
+		meta.NextAttemptAt = time.Unix(nextAttemptAt.Int64, 0).UTC()
+	}
+
+	return meta, nil
+}
+
+// SaveMeta persists meta for domain, creating the row (in the "added" state,
+// with no certificate material yet) if it doesn't already exist.
+func (s *SQLCertificatesStorage) SaveMeta(domain string, meta ResourceMeta) error {
+	metaJSON, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("marshaling metadata for domain %s: %w", domain, err)
+	}
+
+	var nextAttemptAt interface{}
+	if !meta.NextAttemptAt.IsZero() {
+		nextAttemptAt = meta.NextAttemptAt.UTC().Unix()
+	}
+
+	const upsert = `
+INSERT INTO certificates (account, domain, state, cert_pem, meta_json, next_attempt_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (account, domain) DO UPDATE SET
+	meta_json       = excluded.meta_json,
+	next_attempt_at = excluded.next_attempt_at,
+	updated_at      = excluded.updated_at`
+
+	_, err = s.db.Exec(upsert, s.account, domain, certStateAdded, []byte{}, string(metaJSON), nextAttemptAt, time.Now().UTC().Unix())
+	if err != nil {
+		return fmt.Errorf("saving metadata for domain %s: %w", domain, err)
+	}
+
+	return nil
+}
+
+func columnForExtension(extension string) (string, error) {
+	switch extension {
+	case ".crt":
+		return "cert_pem", nil
+	case ".key":
+		return "key_pem", nil
+	case ".issuer.crt":
+		return "issuer_pem", nil
+	default:
+		return "", fmt.Errorf("unsupported extension %q for the sqlite storage backend", extension)
+	}
+}
@@ -0,0 +1,83 @@
+package renewer
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-acme/lego/v3/certificate"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLCertificatesStorage(t *testing.T) {
+	dataSourceName := filepath.Join(t.TempDir(), "lego.db")
+
+	storage, err := NewSQLCertificatesStorage(dataSourceName, "account@example.com")
+	require.NoError(t, err)
+
+	err = storage.SaveResourceCtx(context.Background(), &certificate.Resource{
+		Domain:      "example.com",
+		Certificate: []byte("cert"),
+	}, ResourceMeta{})
+	require.NoError(t, err)
+
+	domains, err := storage.ListCertificates()
+	require.NoError(t, err)
+	require.Equal(t, []string{"example.com"}, domains)
+
+	content, err := storage.ReadFile("example.com", ".crt")
+	require.NoError(t, err)
+	require.Equal(t, []byte("cert"), content)
+
+	nextAttempt := time.Now().UTC().Truncate(time.Second)
+	err = storage.SaveMeta("example.com", ResourceMeta{
+		Attempts:      1,
+		LastError:     "boom",
+		NextAttemptAt: nextAttempt,
+	})
+	require.NoError(t, err)
+
+	meta, err := storage.ReadMeta("example.com")
+	require.NoError(t, err)
+	require.Equal(t, 1, meta.Attempts)
+	require.Equal(t, "boom", meta.LastError)
+	require.True(t, meta.NextAttemptAt.Equal(nextAttempt))
+
+	// Re-saving the resource (as a renewal would) upserts the existing
+	// row rather than duplicating it.
+	err = storage.SaveResourceCtx(context.Background(), &certificate.Resource{
+		Domain:      "example.com",
+		Certificate: []byte("cert-v2"),
+	}, ResourceMeta{})
+	require.NoError(t, err)
+
+	domains, err = storage.ListCertificates()
+	require.NoError(t, err)
+	require.Equal(t, []string{"example.com"}, domains)
+
+	content, err = storage.ReadFile("example.com", ".crt")
+	require.NoError(t, err)
+	require.Equal(t, []byte("cert-v2"), content)
+}
+
+func TestSQLCertificatesStorage_SaveMeta_withoutResource(t *testing.T) {
+	dataSourceName := filepath.Join(t.TempDir(), "lego.db")
+
+	storage, err := NewSQLCertificatesStorage(dataSourceName, "account@example.com")
+	require.NoError(t, err)
+
+	// Recording a failed attempt for a domain that was never successfully
+	// obtained must not make it look like a real certificate exists.
+	err = storage.SaveMeta("never-obtained.example.com", ResourceMeta{
+		Attempts:  1,
+		LastError: "boom",
+	})
+	require.NoError(t, err)
+
+	require.False(t, storage.ExistsFile("never-obtained.example.com", ".crt"))
+
+	domains, err := storage.ListCertificates()
+	require.NoError(t, err)
+	require.Empty(t, domains)
+}
@@ -0,0 +1,22 @@
+package renewer
+
+import (
+	"testing"
+
+	"github.com/go-acme/lego/v3/certificate"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileCertificatesStorage_ListCertificates_wildcard(t *testing.T) {
+	storage := NewFileCertificatesStorage(t.TempDir(), false, "")
+	storage.CreateRootFolder()
+
+	storage.SaveResource(&certificate.Resource{
+		Domain:      "*.example.com",
+		Certificate: []byte("cert"),
+	})
+
+	domains, err := storage.ListCertificates()
+	require.NoError(t, err)
+	require.Equal(t, []string{"*.example.com"}, domains)
+}